@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gonfoot/config"
+)
+
+/*
+Without ACME configured, certMonitor used to return almost immediately,
+which under actorGroup's "first actor to return wins" semantics tore
+the whole server down within microseconds of ListenAndServe starting.
+certMonitor's execute contract is to block until ctx is done, same as
+every other monitor.
+*/
+func TestCertMonitorBlocksUntilShutdownWithoutACME(t *testing.T) {
+	s := newTestServer(&config.Config{})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.ctx = ctx
+
+	done := make(chan struct{})
+	go func() {
+		s.certMonitor()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("certMonitor returned before ctx was done")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if !s.tlsAvailable.Load() {
+		t.Fatal("expected tlsAvailable to be true when ACME is disabled")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("certMonitor did not return after ctx was cancelled")
+	}
+}
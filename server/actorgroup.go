@@ -0,0 +1,55 @@
+package server
+
+/*
+actorGroup runs a set of actors concurrently and tears all of them down
+as soon as any one of them returns, à la oklog/run.Group. Each actor
+registers an execute function, which blocks until the actor is done or
+fails, and an interrupt function, which must cause a blocked execute to
+return promptly.
+*/
+type actorGroup struct {
+	actors []groupActor
+}
+
+type groupActor struct {
+	execute   func() error
+	interrupt func(error)
+}
+
+/*
+add registers an actor with the group. Actors are started in
+registration order by run, and every interrupt is called, in
+registration order, as soon as any one actor's execute returns.
+*/
+func (g *actorGroup) add(execute func() error, interrupt func(error)) {
+	g.actors = append(g.actors, groupActor{execute: execute, interrupt: interrupt})
+}
+
+/*
+run starts every registered actor's execute function on its own
+goroutine, waits for the first one to return, calls every actor's
+interrupt so the rest unwind, and returns the error that ended the
+first actor.
+*/
+func (g *actorGroup) run() error {
+	if len(g.actors) == 0 {
+		return nil
+	}
+
+	errs := make(chan error, len(g.actors))
+	for _, a := range g.actors {
+		execute := a.execute
+		go func() {
+			errs <- execute()
+		}()
+	}
+
+	err := <-errs
+	for _, a := range g.actors {
+		a.interrupt(err)
+	}
+	for i := 1; i < len(g.actors); i++ {
+		<-errs
+	}
+	return err
+}
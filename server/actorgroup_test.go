@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+/*
+A no-op interrupt can't unblock an actor that's only waiting on a
+shared context, so run() would hang forever once one actor failed.
+This pins down the fix: every interrupt must be able to cancel that
+context.
+*/
+func TestActorGroupUnblocksAllOnFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var g actorGroup
+	g.add(func() error {
+		return errors.New("boom")
+	}, func(error) { cancel() })
+
+	g.add(func() error {
+		<-ctx.Done()
+		return nil
+	}, func(error) { cancel() })
+
+	done := make(chan error, 1)
+	go func() { done <- g.run() }()
+
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "boom" {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("actorGroup.run() deadlocked")
+	}
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+/*
+Authenticator validates a request and, on success, returns the request
+(optionally annotated with identity information for downstream
+handlers) and true. chainAuth tries a list of Authenticators in turn.
+*/
+type Authenticator interface {
+	authenticate(r *http.Request) (*http.Request, bool)
+}
+
+/*
+chainAuth serves handler with the request returned by the first
+Authenticator that accepts it, letting a route accept several auth
+mechanisms (e.g. basic auth or an OIDC bearer token). Unix-socket
+connections bypass every Authenticator, since peer credentials already
+imply trust. If none accept, the request is rejected with 401.
+*/
+func chainAuth(s *server, handler http.Handler, authenticators ...Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isUnixRequest(r.Context()) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		for _, a := range authenticators {
+			if authed, ok := a.authenticate(r); ok {
+				handler.ServeHTTP(w, authed)
+				return
+			}
+		}
+		s.log.Info("unauthorized request", "method", r.Method, "url", r.URL.Redacted())
+		w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+type basicAuthenticator struct {
+	s *server
+}
+
+func (a *basicAuthenticator) authenticate(r *http.Request) (*http.Request, bool) {
+	user, pass, ok := r.BasicAuth()
+	if !ok || subtle.ConstantTimeCompare([]byte(user), []byte(a.s.config.ExampleBasicAuthUser)) != 1 || subtle.ConstantTimeCompare([]byte(pass), []byte(a.s.config.ExampleBasicAuthPassword)) != 1 {
+		return r, false
+	}
+	a.s.log.Info("authorized request", "method", r.Method, "url", r.URL.Redacted(), "user", user)
+	return r, true
+}
+
+type oidcClaimsKey struct{}
+
+/*
+OIDCClaims is the subset of a validated bearer token's claims that
+downstream handlers can read back out of the request context via
+ClaimsFromContext.
+*/
+type OIDCClaims struct {
+	Issuer   string
+	Subject  string
+	Audience []string
+	Expiry   time.Time
+}
+
+/*
+ClaimsFromContext returns the OIDC claims oidcAuthenticator attached to
+a request's context, if the request was authenticated that way.
+*/
+func ClaimsFromContext(ctx context.Context) (OIDCClaims, bool) {
+	claims, ok := ctx.Value(oidcClaimsKey{}).(OIDCClaims)
+	return claims, ok
+}
+
+type oidcAuthenticator struct {
+	s        *server
+	issuer   string
+	audience string
+	jwks     *jwksCache
+}
+
+func newOIDCAuthenticator(s *server, issuerURL, audience string, jwksRefreshInterval time.Duration) *oidcAuthenticator {
+	return &oidcAuthenticator{
+		s:        s,
+		issuer:   issuerURL,
+		audience: audience,
+		jwks:     newJWKSCache(strings.TrimSuffix(issuerURL, "/")+"/.well-known/jwks.json", jwksRefreshInterval),
+	}
+}
+
+/*
+authenticate implements OIDC bearer-token auth: it extracts a Bearer token,
+verifies its signature against the cached JWKS, validates iss, aud,
+exp, and nbf, and on success attaches the claims to the request context.
+*/
+func (a *oidcAuthenticator) authenticate(r *http.Request) (*http.Request, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return r, false
+	}
+
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, a.jwks.keyFunc,
+		jwt.WithIssuer(a.issuer),
+		jwt.WithAudience(a.audience),
+		jwt.WithExpirationRequired())
+	if err != nil || !parsed.Valid {
+		a.s.log.Info("rejected OIDC bearer token", "err", err)
+		return r, false
+	}
+
+	oidcClaims := OIDCClaims{Issuer: a.issuer}
+	if sub, err := claims.GetSubject(); err == nil {
+		oidcClaims.Subject = sub
+	}
+	if aud, err := claims.GetAudience(); err == nil {
+		oidcClaims.Audience = aud
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		oidcClaims.Expiry = exp.Time
+	}
+
+	a.s.log.Info("authorized OIDC request", "method", r.Method, "url", r.URL.Redacted(), "subject", oidcClaims.Subject)
+	return r.WithContext(context.WithValue(r.Context(), oidcClaimsKey{}, oidcClaims)), true
+}
+
+/*
+jwksCache fetches and caches a JSON Web Key Set, refreshing it on
+jwksCache.refreshLoop's interval or on demand when an unrecognized kid
+is seen (so a freshly rotated key doesn't have to wait for the next
+tick).
+*/
+type jwksCache struct {
+	url             string
+	refreshInterval time.Duration
+
+	mu   sync.RWMutex
+	keys map[string]*rsa.PublicKey
+}
+
+func newJWKSCache(url string, refreshInterval time.Duration) *jwksCache {
+	return &jwksCache{url: url, refreshInterval: refreshInterval, keys: map[string]*rsa.PublicKey{}}
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func (k jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent: %w", err)
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+func (c *jwksCache) refresh() error {
+	resp, err := http.Get(c.url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var set jsonWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		key, err := k.rsaPublicKey()
+		if err != nil {
+			return fmt.Errorf("failed to parse JWK %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *jwksCache) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	c.mu.RLock()
+	key, ok := c.keys[kid]
+	c.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if err := c.refresh(); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (c *jwksCache) refreshLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.refresh()
+		}
+	}
+}
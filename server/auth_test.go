@@ -0,0 +1,192 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gonfoot/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestServer(cfg *config.Config) *server {
+	return &server{
+		log:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		config: serverConfig{Config: cfg},
+	}
+}
+
+type fakeJWKSServer struct {
+	*httptest.Server
+	keys map[string]*rsa.PrivateKey
+}
+
+func newFakeJWKSServer(t *testing.T) *fakeJWKSServer {
+	t.Helper()
+	f := &fakeJWKSServer{keys: map[string]*rsa.PrivateKey{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		set := jsonWebKeySet{}
+		for kid, key := range f.keys {
+			set.Keys = append(set.Keys, jsonWebKey{
+				Kid: kid,
+				Kty: "RSA",
+				N:   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big64(key.PublicKey.E)),
+			})
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	return f
+}
+
+func big64(e int) []byte {
+	b := []byte{byte(e >> 16), byte(e >> 8), byte(e)}
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+	return b
+}
+
+func (f *fakeJWKSServer) addKey(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	f.keys[kid] = key
+	return key
+}
+
+func (f *fakeJWKSServer) signToken(t *testing.T, kid, issuer, audience string, expiry time.Time) string {
+	t.Helper()
+	key, ok := f.keys[kid]
+	if !ok {
+		t.Fatalf("no such key %q", kid)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": issuer,
+		"aud": audience,
+		"sub": "user-1",
+		"exp": expiry.Unix(),
+		"nbf": time.Now().Add(-time.Minute).Unix(),
+	})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return signed
+}
+
+func TestOIDCAuthenticatorValidToken(t *testing.T) {
+	jwks := newFakeJWKSServer(t)
+	defer jwks.Close()
+	jwks.addKey(t, "key-1")
+
+	a := &oidcAuthenticator{
+		s:        newTestServer(&config.Config{}),
+		issuer:   "https://issuer.example.com",
+		audience: "my-client",
+		jwks:     newJWKSCache(jwks.URL, time.Hour),
+	}
+
+	token := jwks.signToken(t, "key-1", a.issuer, a.audience, time.Now().Add(time.Hour))
+	r := httptest.NewRequest(http.MethodGet, "/api/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	authed, ok := a.authenticate(r)
+	if !ok {
+		t.Fatal("expected token to be accepted")
+	}
+	claims, ok := ClaimsFromContext(authed.Context())
+	if !ok || claims.Subject != "user-1" {
+		t.Fatalf("expected claims with subject user-1, got %+v (ok=%v)", claims, ok)
+	}
+}
+
+func TestOIDCAuthenticatorRejectsExpiredToken(t *testing.T) {
+	jwks := newFakeJWKSServer(t)
+	defer jwks.Close()
+	jwks.addKey(t, "key-1")
+
+	a := &oidcAuthenticator{
+		s:        newTestServer(&config.Config{}),
+		issuer:   "https://issuer.example.com",
+		audience: "my-client",
+		jwks:     newJWKSCache(jwks.URL, time.Hour),
+	}
+
+	token := jwks.signToken(t, "key-1", a.issuer, a.audience, time.Now().Add(-time.Hour))
+	r := httptest.NewRequest(http.MethodGet, "/api/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	if _, ok := a.authenticate(r); ok {
+		t.Fatal("expected expired token to be rejected")
+	}
+}
+
+func TestOIDCAuthenticatorRotatingKey(t *testing.T) {
+	jwks := newFakeJWKSServer(t)
+	defer jwks.Close()
+	jwks.addKey(t, "key-1")
+
+	a := &oidcAuthenticator{
+		s:        newTestServer(&config.Config{}),
+		issuer:   "https://issuer.example.com",
+		audience: "my-client",
+		jwks:     newJWKSCache(jwks.URL, time.Hour),
+	}
+
+	// Prime the cache with the original key, then rotate to a new kid
+	// the cache hasn't seen yet. authenticate must refresh on demand
+	// rather than wait for the next tick.
+	warmup := jwks.signToken(t, "key-1", a.issuer, a.audience, time.Now().Add(time.Hour))
+	r := httptest.NewRequest(http.MethodGet, "/api/", nil)
+	r.Header.Set("Authorization", "Bearer "+warmup)
+	if _, ok := a.authenticate(r); !ok {
+		t.Fatal("expected initial token to be accepted")
+	}
+
+	jwks.addKey(t, "key-2")
+	rotated := jwks.signToken(t, "key-2", a.issuer, a.audience, time.Now().Add(time.Hour))
+	r = httptest.NewRequest(http.MethodGet, "/api/", nil)
+	r.Header.Set("Authorization", "Bearer "+rotated)
+
+	if _, ok := a.authenticate(r); !ok {
+		t.Fatal("expected token signed with rotated key to be accepted")
+	}
+}
+
+func TestChainAuthFallsThroughToBasicAuth(t *testing.T) {
+	s := newTestServer(&config.Config{
+		ExampleBasicAuthUser:     "admin",
+		ExampleBasicAuthPassword: "secret",
+	})
+
+	jwks := newFakeJWKSServer(t)
+	defer jwks.Close()
+	jwks.addKey(t, "key-1")
+	oidc := &oidcAuthenticator{s: s, issuer: "https://issuer.example.com", audience: "my-client", jwks: newJWKSCache(jwks.URL, time.Hour)}
+
+	var called bool
+	handler := chainAuth(s, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), oidc, &basicAuthenticator{s: s})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/", nil)
+	r.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if !called {
+		t.Fatalf("expected request with valid basic auth to reach handler, got status %d", w.Code)
+	}
+}
@@ -1,22 +1,11 @@
 package server
 
 import (
-	"crypto/subtle"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 )
 
-func upgradeHandler(base http.Handler, upgrade http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.ProtoMajor == 2 && r.Header.Get("Content-Type") == "application/grpc" {
-			upgrade.ServeHTTP(w, r)
-		} else {
-			base.ServeHTTP(w, r)
-		}
-	})
-}
-
 func logger(s *server, handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		s.log.Info("request", "method", r.Method, "url", r.URL.Redacted())
@@ -46,7 +35,7 @@ func mustReverseProxy(s *server, rawURL string) http.Handler {
 
 func onlyWhenReady(s *server, handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !s.ready.Load() {
+		if !s.isReady() {
 			http.Error(w, "service not ready", http.StatusServiceUnavailable)
 			s.log.Info("service called when not ready", "method", r.Method, "url", r.URL.Redacted())
 			return
@@ -55,16 +44,11 @@ func onlyWhenReady(s *server, handler http.Handler) http.Handler {
 	})
 }
 
+/*
+requireBasicAuth protects handler with HTTP basic auth only. Routes
+that should also accept an OIDC bearer token should call chainAuth
+directly instead.
+*/
 func requireBasicAuth(s *server, handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, pass, ok := r.BasicAuth()
-		if !ok || subtle.ConstantTimeCompare([]byte(user), []byte(s.config.ExampleBasicAuthUser)) != 1 || subtle.ConstantTimeCompare([]byte(pass), []byte(s.config.ExampleBasicAuthPassword)) != 1 {
-			s.log.Info("unauthorized request", "method", r.Method, "url", r.URL.Redacted(), "user", user)
-			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-		s.log.Info("authorized request", "method", r.Method, "url", r.URL.Redacted(), "user", user)
-		handler.ServeHTTP(w, r)
-	})
+	return chainAuth(s, handler, &basicAuthenticator{s: s})
 }
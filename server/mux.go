@@ -0,0 +1,117 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/soheilhy/cmux"
+	"google.golang.org/grpc"
+)
+
+/*
+serveListener splits the single root listener into protocol-specific
+sub-listeners with cmux and serves each on its own goroutine:
+
+  - gRPC (cleartext, identified by the "application/grpc" content-type
+    header in the client's HTTP/2 HEADERS frame; cmux sends its own
+    SETTINGS frame first so clients that wait for it don't stall)
+  - TLS (HTTPS and gRPC-over-TLS, demultiplexed again behind the
+    handshake by serveTLS)
+  - everything else, handled as plain HTTP/1.1
+
+It blocks until the root listener is closed, at which point all
+sub-listeners have also stopped accepting and the method returns.
+*/
+func (s *server) serveListener(lis net.Listener) error {
+	m := cmux.New(lis)
+
+	grpcL := m.MatchWithWriters(cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"))
+	tlsL := m.Match(cmux.TLS())
+	httpL := m.Match(cmux.Any())
+
+	var wg sync.WaitGroup
+	serve := func(name string, fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(); err != nil && !isIgnorableServeErr(err) {
+				s.log.Error("sub-listener serve error", "listener", name, "err", err)
+			}
+		}()
+	}
+
+	serve("grpc", func() error { return s.grpcServer.Serve(grpcL) })
+	serve("http", func() error { return s.httpServer.Serve(httpL) })
+	serve("tls", func() error { return s.serveTLS(tlsL) })
+
+	err := m.Serve()
+	wg.Wait()
+	if err != nil && !isIgnorableServeErr(err) {
+		return err
+	}
+	return nil
+}
+
+/*
+serveTLS terminates TLS on lis and runs a second, inner cmux over the
+decrypted stream so that both HTTPS and gRPC-over-TLS can share the
+same TLS listener. If no TLS certificate is configured, connections are
+accepted and immediately closed so well-behaved clients fail fast
+instead of hanging on a handshake no one will complete.
+*/
+func (s *server) serveTLS(lis net.Listener) error {
+	tlsConfig := s.tlsConfig
+	if tlsConfig == nil {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return err
+			}
+			conn.Close()
+		}
+	}
+
+	tlsLis := tls.NewListener(lis, tlsConfig)
+	m := cmux.New(tlsLis)
+	grpcL := m.MatchWithWriters(cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"))
+	httpsL := m.Match(cmux.Any())
+
+	go func() {
+		if err := s.grpcServer.Serve(grpcL); err != nil && !isIgnorableServeErr(err) {
+			s.log.Error("grpc-tls serve error", "err", err)
+		}
+	}()
+	go func() {
+		if err := s.httpServer.Serve(httpsL); err != nil && !isIgnorableServeErr(err) {
+			s.log.Error("https serve error", "err", err)
+		}
+	}()
+
+	return m.Serve()
+}
+
+func isIgnorableServeErr(err error) bool {
+	return errors.Is(err, cmux.ErrListenerClosed) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, http.ErrServerClosed) ||
+		errors.Is(err, grpc.ErrServerStopped)
+}
+
+/*
+loadTLSConfig builds a *tls.Config from the configured cert/key pair,
+or returns nil if TLS is not configured so callers can treat it as
+"native TLS disabled".
+*/
+func loadTLSConfig(certPath, keyPath string) (*tls.Config, error) {
+	if certPath == "" || keyPath == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
@@ -5,6 +5,7 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log/slog"
 	"net"
@@ -22,19 +23,21 @@ import (
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"golang.org/x/net/http2"
-	"golang.org/x/net/http2/h2c"
+	"golang.org/x/crypto/acme/autocert"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
 type serverConfig struct {
 	*config.Config
-	httpAddr        string
-	dsn             string
-	shutdownTimeout time.Duration
-	monitorInterval time.Duration
+	httpAddr               string
+	dsn                    string
+	shutdownTimeout        time.Duration
+	monitorInterval        time.Duration
+	tlsLetsEncryptHostname string
 }
 
 type server struct {
@@ -52,20 +55,36 @@ type server struct {
 	httpServerAvailable atomic.Bool
 	databaseAvailable   atomic.Bool
 
-	httpServer *http.Server
-	httpClosed chan bool
+	tlsAvailable atomic.Bool
+
+	grpcServer        *grpc.Server
+	httpServer        *http.Server
+	tlsConfig         *tls.Config
+	autocertManager   *autocert.Manager
+	oidcAuthenticator *oidcAuthenticator
+	listener          net.Listener
+	unixListener      net.Listener
+}
+
+/*
+isReady reports whether the server should be considered ready to take
+traffic: readyMonitor's own check, the database, and (if ACME is
+configured) a currently obtainable TLS certificate all have to hold.
+*/
+func (s *server) isReady() bool {
+	return s.ready.Load() && s.databaseAvailable.Load() && s.tlsAvailable.Load()
 }
 
 /*
-New creates a new server with the provided configuration.
+New creates a new server with the provided configuration. Additional
+Options may be passed to prepend gRPC interceptors (e.g. auth, tracing)
+ahead of the package's own metrics and logging interceptors.
 */
-func New(ctx context.Context, log *slog.Logger, config *config.Config) (*server, error) {
+func New(ctx context.Context, log *slog.Logger, config *config.Config, opts ...Option) (*server, error) {
 	s := &server{
 		ctx:    ctx,
 		config: serverConfig{Config: config},
 		log:    log,
-
-		httpClosed: make(chan bool),
 	}
 	s.config.monitorInterval = time.Duration(config.MonitorInterval) * time.Second
 	s.config.shutdownTimeout = time.Duration(config.HttpShutdownGracePeriod) * time.Second
@@ -73,15 +92,55 @@ func New(ctx context.Context, log *slog.Logger, config *config.Config) (*server,
 	s.config.dsn = fmt.Sprintf("postgres://%s:%s@%s:%d/%s?search_path=%s", config.PostgresUsername, config.PostgresPassword, config.PostgresHost, config.PostgresPort, config.PostgresDatabase, config.PostgresSchema)
 	s.db = db.New(s.ctx, s.config.dsn, log)
 
+	tlsConfig, err := loadTLSConfig(config.TLSCertPath, config.TLSKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	s.tlsConfig = tlsConfig
+
+	if config.OIDCIssuerURL != "" {
+		audience := config.OIDCAudience
+		if audience == "" {
+			audience = config.OIDCClientID
+		}
+		refreshInterval := time.Duration(config.OIDCJWKSRefreshInterval) * time.Second
+		s.oidcAuthenticator = newOIDCAuthenticator(s, config.OIDCIssuerURL, audience, refreshInterval)
+		go s.oidcAuthenticator.jwks.refreshLoop(s.ctx)
+	}
+
+	s.autocertManager = newAutocertManager(config.TLSLetsEncryptHostnames, config.TLSLetsEncryptCacheDir, config.TLSLetsEncryptEmail, config.ACMEDirectoryURL)
+	if s.autocertManager != nil {
+		if len(config.TLSLetsEncryptHostnames) > 0 {
+			s.config.tlsLetsEncryptHostname = config.TLSLetsEncryptHostnames[0]
+		}
+		s.tlsConfig = s.autocertManager.TLSConfig()
+	}
+
 	s.live.Store(false)
 	s.ready.Store(false)
 	s.shutdownRequested.Store(false)
 	s.httpServerAvailable.Store(false)
 	s.databaseAvailable.Store(false)
+	s.tlsAvailable.Store(s.autocertManager == nil)
 
-	grpcOptions := []grpc.ServerOption{grpc.Creds(insecure.NewCredentials())}
-	grpcServer := grpc.NewServer(grpcOptions...)
-	pb.RegisterAPIServer(grpcServer, s)
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	o.unaryInterceptors = append(o.unaryInterceptors, grpcMetricsUnaryInterceptor(), grpcLoggingUnaryInterceptor(s))
+	o.streamInterceptors = append(o.streamInterceptors, grpcMetricsStreamInterceptor(), grpcLoggingStreamInterceptor(s))
+
+	grpcOptions := []grpc.ServerOption{
+		grpc.Creds(insecure.NewCredentials()),
+		grpc.ChainUnaryInterceptor(o.unaryInterceptors...),
+		grpc.ChainStreamInterceptor(o.streamInterceptors...),
+	}
+	s.grpcServer = grpc.NewServer(grpcOptions...)
+	pb.RegisterAPIServer(s.grpcServer, s)
+	grpc_health_v1.RegisterHealthServer(s.grpcServer, &healthServer{s: s})
+	if config.EnableGRPCReflection {
+		reflection.Register(s.grpcServer)
+	}
 	grpcMux := runtime.NewServeMux(runtime.WithOutgoingHeaderMatcher(func(s string) (string, bool) {
 		return s, true
 	}), runtime.WithMarshalerOption("*", &httpBodyMarshaler{
@@ -100,29 +159,47 @@ func New(ctx context.Context, log *slog.Logger, config *config.Config) (*server,
 	}
 
 	mux := http.NewServeMux()
-	mux.Handle("/", requireBasicAuth(s, http.NotFoundHandler()))
+	rootHandler := requireBasicAuth(s, http.NotFoundHandler())
+	if s.autocertManager != nil {
+		rootHandler = acmeHandler(s.autocertManager, rootHandler)
+	}
+	mux.Handle("/", rootHandler)
 	mux.Handle("/favicon.ico", http.FileServer(http.FS(static.Http)))
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(static.Http))))
 	mux.Handle("/metrics", promhttp.Handler())
-	mux.Handle("/api/", http.StripPrefix("/api", onlyWhenReady(s, logger(s, grpcMux))))
+	apiAuthenticators := []Authenticator{&basicAuthenticator{s: s}}
+	if s.oidcAuthenticator != nil {
+		apiAuthenticators = append([]Authenticator{s.oidcAuthenticator}, apiAuthenticators...)
+	}
+	mux.Handle("/api/", http.StripPrefix("/api", onlyWhenReady(s, logger(s, chainAuth(s, grpcMux, apiAuthenticators...)))))
 
 	mux.Handle("/examplereverseproxy/", logger(s, mustReverseProxy(s, s.config.ExampleReverseProxyURL)))
 	s.mux = mux
 
 	s.httpServer = &http.Server{
-		Addr:    s.config.httpAddr,
-		Handler: h2c.NewHandler(upgradeHandler(s.mux, grpcServer), &http2.Server{}),
+		Addr:        s.config.httpAddr,
+		Handler:     s.mux,
+		TLSConfig:   s.tlsConfig,
+		ConnContext: unixConnContext,
 	}
 
 	return s, nil
 }
 
+/*
+ListenAndServe brings the server up and blocks until it shuts down.
+Every long-running piece of the server - the multiplexed HTTP/gRPC
+listener, the optional Unix socket listener, and the live/ready/db/cert
+monitors - is registered with an actorGroup so that any one of them
+failing tears the rest down within shutdownTimeout instead of leaving
+orphaned goroutines behind. s.ctx is replaced with a child context for
+the duration of the call so every monitor (which all select on
+s.ctx.Done()) unblocks the moment any actor fails, not just when the
+caller's own context is cancelled. ListenAndServe returns the error
+that ended the first actor, or nil on a clean shutdown signaled via
+ctx.
+*/
 func (s *server) ListenAndServe() error {
-	go s.listenAndServe()
-	go s.liveMonitor()
-	go s.readyMonitor()
-	go s.dbMonitor()
-
 	if err := s.db.Connect(); err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -131,39 +208,103 @@ func (s *server) ListenAndServe() error {
 		return nil
 	}
 
-	for {
+	runCtx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	s.ctx = runCtx
+
+	var g actorGroup
+
+	g.add(s.listenAndServe, func(error) {
+		cancel()
+		s.httpServerAvailable.CompareAndSwap(true, false)
+		s.shutdownRequested.CompareAndSwap(false, true)
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), s.config.shutdownTimeout)
+		defer shutdownCancel()
+
+		if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
+			s.log.Error("error during http server shutdown", "err", err)
+		} else {
+			s.log.Info("http server shutdown gracefully")
+		}
+
+		grpcStopped := make(chan struct{})
+		go func() {
+			s.grpcServer.GracefulStop()
+			close(grpcStopped)
+		}()
 		select {
-		case <-s.httpClosed:
-			s.log.Info("http server closed")
-			s.httpServerAvailable.CompareAndSwap(true, false)
-			return nil
-		case <-s.ctx.Done():
-			s.log.Info("shutdown signal received", "signal", s.ctx.Err())
-			s.httpServerAvailable.CompareAndSwap(true, false)
-			s.shutdownRequested.CompareAndSwap(false, true)
-			ctx, cancel := context.WithTimeout(context.Background(), s.config.shutdownTimeout)
-			defer cancel()
-			if err := s.httpServer.Shutdown(ctx); err != nil {
-				return fmt.Errorf("error during http server shutdown: %w", err)
-			} else {
-				s.log.Info("http server shutdown gracefully")
-			}
-			return nil
+		case <-grpcStopped:
+		case <-shutdownCtx.Done():
+			s.log.Error("grpc graceful stop exceeded shutdown timeout, forcing stop")
+			s.grpcServer.Stop()
+			<-grpcStopped
+		}
+
+		if s.listener != nil {
+			s.listener.Close()
 		}
+	})
+
+	if s.config.UnixSocket != "" {
+		unixDone := make(chan struct{})
+		g.add(func() error {
+			defer close(unixDone)
+			return s.serveUnixSocket(s.config.UnixSocket)
+		}, func(error) {
+			cancel()
+			if s.unixListener != nil {
+				s.unixListener.Close()
+			}
+			<-unixDone
+		})
+	}
+
+	g.add(func() error {
+		s.liveMonitor()
+		return nil
+	}, func(error) { cancel() })
+
+	g.add(func() error {
+		s.readyMonitor()
+		return nil
+	}, func(error) { cancel() })
+
+	g.add(func() error {
+		s.dbMonitor()
+		return nil
+	}, func(error) { cancel() })
+
+	g.add(func() error {
+		s.certMonitor()
+		return nil
+	}, func(error) { cancel() })
+
+	g.add(func() error {
+		<-s.ctx.Done()
+		return nil
+	}, func(error) { cancel() })
+
+	err := g.run()
+	if closeErr := s.db.Close(); closeErr != nil {
+		s.log.Error("error closing database", "err", closeErr)
 	}
+	return err
 }
 
-func (s *server) listenAndServe() {
+func (s *server) listenAndServe() error {
 	s.httpServerAvailable.Store(true)
 	lis, err := net.Listen("tcp", s.config.httpAddr)
 	if err != nil {
 		s.log.Error("Failed to listen", "err", err, "addr", s.config.httpAddr)
-		s.httpClosed <- true
-		return
+		return fmt.Errorf("failed to listen on %s: %w", s.config.httpAddr, err)
 	}
-	if err := s.httpServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+	s.listener = lis
+	if err := s.serveListener(lis); err != nil {
 		s.log.Error("Unexpected http server error", "err", err, "addr", s.config.httpAddr)
 		s.httpServerAvailable.CompareAndSwap(true, false)
-		s.httpClosed <- true
+		return err
 	}
+	s.httpServerAvailable.CompareAndSwap(true, false)
+	return nil
 }
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+)
+
+type unixConnKey struct{}
+
+/*
+unixListener wraps a net.Listener so every accepted connection is
+annotated in its context as having arrived over a Unix domain socket.
+chainAuth (and so requireBasicAuth) inspects this to skip auth for
+local, peer-creds-backed callers.
+*/
+type unixListener struct {
+	net.Listener
+}
+
+func (l *unixListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &unixConn{Conn: conn}, nil
+}
+
+type unixConn struct {
+	net.Conn
+}
+
+func isUnixRequest(ctx context.Context) bool {
+	v, _ := ctx.Value(unixConnKey{}).(bool)
+	return v
+}
+
+/*
+unixConnContext is installed as http.Server.ConnContext so handlers can
+tell, via isUnixRequest, whether a request arrived over the Unix socket
+listener rather than TCP.
+*/
+func unixConnContext(ctx context.Context, c net.Conn) context.Context {
+	if _, ok := c.(*unixConn); ok {
+		return context.WithValue(ctx, unixConnKey{}, true)
+	}
+	return ctx
+}
+
+/*
+listenUnixSocket listens on path, chmods it to 0600 so only the owning
+user can connect, and returns a listener whose connections are tagged
+for unixConnContext. The caller is responsible for removing the socket
+file on shutdown.
+*/
+func listenUnixSocket(path string) (net.Listener, error) {
+	os.Remove(path)
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		lis.Close()
+		return nil, err
+	}
+	return &unixListener{Listener: lis}, nil
+}
+
+/*
+serveUnixSocket runs httpServer on the Unix listener until it is
+closed, removing the socket file on the way out.
+*/
+func (s *server) serveUnixSocket(path string) error {
+	lis, err := listenUnixSocket(path)
+	if err != nil {
+		return err
+	}
+	s.unixListener = lis
+	defer os.Remove(path)
+	if err := s.httpServer.Serve(lis); err != nil && !isIgnorableServeErr(err) {
+		return err
+	}
+	return nil
+}